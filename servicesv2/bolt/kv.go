@@ -0,0 +1,238 @@
+// Package bolt provides a kv.SchemaStore implementation backed by a single
+// boltdb file on disk.
+package bolt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/influxdata/influxdb/servicesv2/kv"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// KVStore is a kv.SchemaStore backed by a boltdb file.
+type KVStore struct {
+	path   string
+	db     *bbolt.DB
+	logger *zap.Logger
+}
+
+// NewKVStore returns a KVStore that will open the boltdb file at path.
+func NewKVStore(logger *zap.Logger, path string) *KVStore {
+	return &KVStore{
+		path:   path,
+		logger: logger,
+	}
+}
+
+// Open opens and initializes the boltdb file.
+func (s *KVStore) Open(ctx context.Context) error {
+	db, err := bbolt.Open(s.path, 0666, nil)
+	if err != nil {
+		return fmt.Errorf("unable to open boltdb file %s: %w", s.path, err)
+	}
+
+	s.db = db
+	return nil
+}
+
+// Close closes the store's underlying boltdb file.
+func (s *KVStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// CreateBucket creates a bucket if it does not already exist.
+func (s *KVStore) CreateBucket(ctx context.Context, bucket []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+}
+
+// DeleteBucket deletes a bucket, if it exists.
+func (s *KVStore) DeleteBucket(ctx context.Context, bucket []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.DeleteBucket(bucket)
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// View opens up a read-only transaction against the store.
+func (s *KVStore) View(ctx context.Context, fn func(kv.Tx) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return fn(&Tx{tx: tx, ctx: ctx})
+	})
+}
+
+// Update opens up a read-write transaction against the store.
+func (s *KVStore) Update(ctx context.Context, fn func(kv.Tx) error) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&Tx{tx: tx, ctx: ctx})
+	})
+}
+
+// Tx is a kv.Tx backed by a bbolt transaction.
+type Tx struct {
+	tx  *bbolt.Tx
+	ctx context.Context
+}
+
+// Context returns the context associated with this transaction.
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// WithContext associates a context with this transaction.
+func (tx *Tx) WithContext(ctx context.Context) {
+	tx.ctx = ctx
+}
+
+// Bucket retrieves the bucket named b.
+func (tx *Tx) Bucket(b []byte) (kv.Bucket, error) {
+	bkt := tx.tx.Bucket(b)
+	if bkt == nil {
+		return nil, kv.ErrBucketNotFound
+	}
+	return &Bucket{bucket: bkt}, nil
+}
+
+// Bucket is a kv.Bucket backed by a bbolt bucket.
+type Bucket struct {
+	bucket *bbolt.Bucket
+}
+
+// Get retrieves the value at key in the bucket.
+func (b *Bucket) Get(key []byte) ([]byte, error) {
+	val := b.bucket.Get(key)
+	if val == nil {
+		return nil, kv.ErrKeyNotFound
+	}
+	return val, nil
+}
+
+// Put sets the value at key in the bucket.
+func (b *Bucket) Put(key, value []byte) error {
+	return b.bucket.Put(key, value)
+}
+
+// Delete removes the key from the bucket.
+func (b *Bucket) Delete(key []byte) error {
+	return b.bucket.Delete(key)
+}
+
+// Cursor opens a cursor at the beginning of the bucket. When hints carry a
+// Prefix, the returned cursor is scoped to keys under that prefix.
+func (b *Bucket) Cursor(hints ...kv.CursorHint) (kv.Cursor, error) {
+	var prefix []byte
+	for _, h := range hints {
+		if len(h.Prefix) > 0 {
+			prefix = h.Prefix
+		}
+	}
+
+	return &Cursor{cursor: b.bucket.Cursor(), prefix: prefix}, nil
+}
+
+// Cursor is a kv.Cursor backed by a bbolt cursor.
+type Cursor struct {
+	cursor *bbolt.Cursor
+	prefix []byte
+	err    error
+}
+
+func (c *Cursor) inPrefix(k []byte) bool {
+	if k == nil || len(c.prefix) == 0 {
+		return k != nil
+	}
+	return len(k) >= len(c.prefix) && string(k[:len(c.prefix)]) == string(c.prefix)
+}
+
+// Seek moves the cursor forward to the first key >= to key.
+func (c *Cursor) Seek(key []byte) ([]byte, []byte) {
+	k, v := c.cursor.Seek(key)
+	if !c.inPrefix(k) {
+		return nil, nil
+	}
+	return k, v
+}
+
+// First moves the cursor to the first key in the bucket (or prefix).
+func (c *Cursor) First() ([]byte, []byte) {
+	var k, v []byte
+	if len(c.prefix) > 0 {
+		k, v = c.cursor.Seek(c.prefix)
+	} else {
+		k, v = c.cursor.First()
+	}
+	if !c.inPrefix(k) {
+		return nil, nil
+	}
+	return k, v
+}
+
+// Last moves the cursor to the last key in the bucket (or prefix).
+func (c *Cursor) Last() ([]byte, []byte) {
+	var k, v []byte
+	if upper := prefixUpperBound(c.prefix); upper != nil {
+		// Seek lands on the first key >= upper, i.e. just past the prefix's
+		// range; stepping back one lands on the prefix's last key, if any.
+		// A nil result means every key in the bucket is < upper, so the
+		// prefix's last key (if it exists at all) is the bucket's last key.
+		if k, v = c.cursor.Seek(upper); k != nil {
+			k, v = c.cursor.Prev()
+		} else {
+			k, v = c.cursor.Last()
+		}
+	} else {
+		k, v = c.cursor.Last()
+	}
+	if !c.inPrefix(k) {
+		return nil, nil
+	}
+	return k, v
+}
+
+// prefixUpperBound returns the smallest byte slice that sorts after every
+// key with the given prefix, or nil if prefix is empty or consists entirely
+// of 0xff bytes (in which case no such upper bound exists and the caller
+// should fall back to the end of the bucket).
+func prefixUpperBound(prefix []byte) []byte {
+	upper := append([]byte{}, prefix...)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xff {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
+
+// Next moves the cursor to the next key in the bucket (or prefix).
+func (c *Cursor) Next() ([]byte, []byte) {
+	k, v := c.cursor.Next()
+	if !c.inPrefix(k) {
+		return nil, nil
+	}
+	return k, v
+}
+
+// Prev moves the cursor to the previous key in the bucket (or prefix).
+func (c *Cursor) Prev() ([]byte, []byte) {
+	k, v := c.cursor.Prev()
+	if !c.inPrefix(k) {
+		return nil, nil
+	}
+	return k, v
+}
+
+// Err returns any error that occurred while iterating.
+func (c *Cursor) Err() error {
+	return c.err
+}
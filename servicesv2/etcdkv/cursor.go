@@ -0,0 +1,105 @@
+package etcdkv
+
+import (
+	"context"
+
+	"github.com/influxdata/influxdb/servicesv2/kv"
+	clientv3 "go.etcd.io/etcd/clientv3"
+)
+
+// cursor is a kv.Cursor over a bucket's keys. Since an STM only supports
+// single-key reads, a cursor always works off of a snapshot of the bucket
+// fetched ahead of time, paginated with WithRange+WithLimit so scanning a
+// large bucket doesn't pull it into memory in a single round trip.
+type cursor struct {
+	entries []entry
+	pos     int
+	err     error
+}
+
+type entry struct {
+	key, value []byte
+}
+
+func newCursor(ctx context.Context, store *KVStore, bucket []byte, hints ...kv.CursorHint) (*cursor, error) {
+	prefix := bucketPrefix(bucket)
+
+	start := prefix
+	for _, h := range hints {
+		if len(h.Prefix) > 0 {
+			start = bucketKey(bucket, h.Prefix)
+		}
+	}
+	end := clientv3.GetPrefixRangeEnd(start)
+
+	var entries []entry
+	from := start
+	for {
+		resp, err := store.client.Get(ctx, from,
+			clientv3.WithRange(end),
+			clientv3.WithLimit(getPageSize),
+			clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range resp.Kvs {
+			entries = append(entries, entry{
+				key:   []byte(string(item.Key)[len(prefix):]),
+				value: item.Value,
+			})
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+
+		// Resume just past the last key seen in this page.
+		from = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	return &cursor{entries: entries, pos: -1}, nil
+}
+
+func (c *cursor) at(i int) ([]byte, []byte) {
+	if i < 0 || i >= len(c.entries) {
+		return nil, nil
+	}
+	return c.entries[i].key, c.entries[i].value
+}
+
+func (c *cursor) First() ([]byte, []byte) {
+	c.pos = 0
+	return c.at(c.pos)
+}
+
+func (c *cursor) Last() ([]byte, []byte) {
+	c.pos = len(c.entries) - 1
+	return c.at(c.pos)
+}
+
+func (c *cursor) Next() ([]byte, []byte) {
+	c.pos++
+	return c.at(c.pos)
+}
+
+func (c *cursor) Prev() ([]byte, []byte) {
+	c.pos--
+	return c.at(c.pos)
+}
+
+func (c *cursor) Seek(key []byte) ([]byte, []byte) {
+	for i, e := range c.entries {
+		if string(e.key) >= string(key) {
+			c.pos = i
+			return c.at(c.pos)
+		}
+	}
+	c.pos = len(c.entries)
+	return nil, nil
+}
+
+func (c *cursor) Err() error {
+	return c.err
+}
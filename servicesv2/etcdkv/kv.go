@@ -0,0 +1,180 @@
+// Package etcdkv provides a kv.SchemaStore implementation backed by an etcd
+// v3 cluster, so tenant metadata can be replicated instead of living in a
+// single bolt file. Buckets map to key prefixes ("/<bucket>/<key>"); reads
+// go through plain etcd Get calls and writes go through an STM
+// (software transactional memory) so that read-modify-write sequences, like
+// the uniqueness checks CreateUser/UpdateUser do against userIndex, stay
+// atomic even with concurrent writers.
+package etcdkv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/servicesv2/kv"
+	clientv3 "go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/clientv3/concurrency"
+	"go.uber.org/zap"
+)
+
+// getPageSize bounds how many keys a Cursor fetches per round trip while
+// ranging over a bucket.
+const getPageSize = 100
+
+// defaultDialTimeout bounds how long Open waits to establish a connection
+// to the etcd cluster.
+const defaultDialTimeout = 5 * time.Second
+
+// bucketMarkerPrefix is where CreateBucket records that a bucket exists, so
+// that fact is visible to every node sharing the cluster, not just the one
+// that happened to run the migration that created it.
+const bucketMarkerPrefix = "/_buckets/"
+
+// KVStore is a kv.SchemaStore backed by an etcd v3 cluster.
+type KVStore struct {
+	endpoints []string
+	logger    *zap.Logger
+
+	client *clientv3.Client
+
+	// buckets caches bucket markers this process has already confirmed
+	// exist in etcd, so hasBucket doesn't round-trip for every Bucket
+	// call. etcd, not this map, is the source of truth: a miss here falls
+	// through to a Get before hasBucket reports a bucket missing.
+	mu      sync.RWMutex
+	buckets map[string]bool
+}
+
+// NewKVStore returns a KVStore that will dial the given etcd endpoints.
+func NewKVStore(logger *zap.Logger, endpoints []string) *KVStore {
+	return &KVStore{
+		endpoints: endpoints,
+		logger:    logger,
+		buckets:   map[string]bool{},
+	}
+}
+
+// Open dials the etcd cluster.
+func (s *KVStore) Open(ctx context.Context) error {
+	c, err := clientv3.New(clientv3.Config{
+		Endpoints:   s.endpoints,
+		Context:     ctx,
+		DialTimeout: defaultDialTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to connect to etcd at %v: %w", s.endpoints, err)
+	}
+
+	s.client = c
+	return nil
+}
+
+// Close closes the store's connection to etcd.
+func (s *KVStore) Close() error {
+	if s.client != nil {
+		return s.client.Close()
+	}
+	return nil
+}
+
+// DeleteAll removes every key this store has written, across every bucket.
+// It exists for tests that share a long-lived etcd cluster across runs and
+// need to start from a clean slate; production code has no use for it.
+func (s *KVStore) DeleteAll(ctx context.Context) error {
+	_, err := s.client.Delete(ctx, "/", clientv3.WithPrefix())
+	return err
+}
+
+// CreateBucket registers bucket as valid for use by this store. Unlike
+// bolt, etcd has no native notion of a bucket, so this writes a marker key
+// to etcd recording that the bucket exists: the registration has to be
+// visible to every node sharing the cluster, not just the one that ran the
+// migration that created it, and has to survive a restart.
+func (s *KVStore) CreateBucket(ctx context.Context, bucket []byte) error {
+	if _, err := s.client.Put(ctx, bucketMarkerKey(bucket), "1"); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buckets[string(bucket)] = true
+	return nil
+}
+
+// DeleteBucket removes bucket's marker and every key stored under it.
+func (s *KVStore) DeleteBucket(ctx context.Context, bucket []byte) error {
+	if _, err := s.client.Delete(ctx, bucketMarkerKey(bucket)); err != nil {
+		return err
+	}
+	if _, err := s.client.Delete(ctx, bucketPrefix(bucket), clientv3.WithPrefix()); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.buckets, string(bucket))
+	s.mu.Unlock()
+	return nil
+}
+
+// hasBucket reports whether bucket has been created. The local cache only
+// ever records positive results it has itself confirmed against etcd, so a
+// cache miss falls through to a Get rather than assuming the bucket is
+// missing: that Get is what lets a second node, or this same node after a
+// restart, see a bucket a migration created somewhere else in the cluster.
+func (s *KVStore) hasBucket(ctx context.Context, bucket []byte) (bool, error) {
+	s.mu.RLock()
+	cached := s.buckets[string(bucket)]
+	s.mu.RUnlock()
+	if cached {
+		return true, nil
+	}
+
+	resp, err := s.client.Get(ctx, bucketMarkerKey(bucket))
+	if err != nil {
+		return false, err
+	}
+	if len(resp.Kvs) == 0 {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	s.buckets[string(bucket)] = true
+	s.mu.Unlock()
+	return true, nil
+}
+
+// View opens a read-only transaction: bucket reads go straight to etcd
+// without taking part in an STM, since there is nothing to make atomic.
+func (s *KVStore) View(ctx context.Context, fn func(kv.Tx) error) error {
+	return fn(&readTx{ctx: ctx, store: s})
+}
+
+// Update opens a read-write transaction backed by an etcd STM, so that any
+// read-modify-write sequence a caller performs (for example, checking
+// userIndex before writing a new entry) is applied atomically.
+func (s *KVStore) Update(ctx context.Context, fn func(kv.Tx) error) error {
+	apply := func(stm concurrency.STM) error {
+		return fn(&stmTx{ctx: ctx, store: s, stm: stm})
+	}
+
+	_, err := concurrency.NewSTM(s.client, apply, concurrency.WithAbortContext(ctx))
+	return err
+}
+
+// bucketPrefix returns the etcd key prefix all of bucket's keys live under.
+func bucketPrefix(bucket []byte) string {
+	return "/" + string(bucket) + "/"
+}
+
+// bucketMarkerKey returns the etcd key recording that bucket has been
+// created.
+func bucketMarkerKey(bucket []byte) string {
+	return bucketMarkerPrefix + string(bucket)
+}
+
+// bucketKey returns the full etcd key for key within bucket.
+func bucketKey(bucket, key []byte) string {
+	return bucketPrefix(bucket) + string(key)
+}
@@ -0,0 +1,125 @@
+package etcdkv
+
+import (
+	"context"
+
+	"github.com/influxdata/influxdb/servicesv2/kv"
+	"go.etcd.io/etcd/clientv3/concurrency"
+)
+
+// readTx is a read-only kv.Tx backed by direct etcd Get calls.
+type readTx struct {
+	ctx   context.Context
+	store *KVStore
+}
+
+func (tx *readTx) Context() context.Context        { return tx.ctx }
+func (tx *readTx) WithContext(ctx context.Context) { tx.ctx = ctx }
+
+func (tx *readTx) Bucket(b []byte) (kv.Bucket, error) {
+	ok, err := tx.store.hasBucket(tx.ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, kv.ErrBucketNotFound
+	}
+	return &readBucket{ctx: tx.ctx, store: tx.store, bucket: b}, nil
+}
+
+// stmTx is a read-write kv.Tx backed by an etcd STM, so read-modify-write
+// sequences within Update are applied atomically.
+type stmTx struct {
+	ctx   context.Context
+	store *KVStore
+	stm   concurrency.STM
+}
+
+func (tx *stmTx) Context() context.Context        { return tx.ctx }
+func (tx *stmTx) WithContext(ctx context.Context) { tx.ctx = ctx }
+
+func (tx *stmTx) Bucket(b []byte) (kv.Bucket, error) {
+	ok, err := tx.store.hasBucket(tx.ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, kv.ErrBucketNotFound
+	}
+	return &stmBucket{ctx: tx.ctx, store: tx.store, bucket: b, stm: tx.stm}, nil
+}
+
+// readBucket is a kv.Bucket that reads directly from etcd; it is only ever
+// handed out by a read-only transaction, so writes are rejected.
+type readBucket struct {
+	ctx    context.Context
+	store  *KVStore
+	bucket []byte
+}
+
+func (b *readBucket) Get(key []byte) ([]byte, error) {
+	resp, err := b.store.client.Get(b.ctx, bucketKey(b.bucket, key))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, kv.ErrKeyNotFound
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (b *readBucket) Put(key, value []byte) error {
+	return errReadOnlyTx
+}
+
+func (b *readBucket) Delete(key []byte) error {
+	return errReadOnlyTx
+}
+
+func (b *readBucket) Cursor(opts ...kv.CursorHint) (kv.Cursor, error) {
+	return newCursor(b.ctx, b.store, b.bucket, opts...)
+}
+
+// stmBucket is a kv.Bucket whose reads and writes are routed through an
+// etcd STM, so a sequence like "check userIndex, then write it" is
+// serialized against other concurrent Updates.
+type stmBucket struct {
+	ctx    context.Context
+	store  *KVStore
+	bucket []byte
+	stm    concurrency.STM
+}
+
+func (b *stmBucket) Get(key []byte) ([]byte, error) {
+	v := b.stm.Get(bucketKey(b.bucket, key))
+	if v == "" {
+		return nil, kv.ErrKeyNotFound
+	}
+	return []byte(v), nil
+}
+
+func (b *stmBucket) Put(key, value []byte) error {
+	b.stm.Put(bucketKey(b.bucket, key), string(value))
+	return nil
+}
+
+func (b *stmBucket) Delete(key []byte) error {
+	b.stm.Del(bucketKey(b.bucket, key))
+	return nil
+}
+
+// Cursor ranges over a snapshot of the bucket taken outside the enclosing
+// STM: etcd's STM only supports single-key reads, so a scan within an
+// Update transaction is necessarily a point-in-time read rather than part
+// of the same optimistic transaction as any Gets/Puts around it.
+func (b *stmBucket) Cursor(opts ...kv.CursorHint) (kv.Cursor, error) {
+	return newCursor(b.ctx, b.store, b.bucket, opts...)
+}
+
+var errReadOnlyTx = &readOnlyTxError{}
+
+type readOnlyTxError struct{}
+
+func (e *readOnlyTxError) Error() string {
+	return "cannot write in a read-only transaction"
+}
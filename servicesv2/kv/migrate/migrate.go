@@ -0,0 +1,124 @@
+// Package migrate provides a small versioned schema migration runner for a
+// kv.SchemaStore. Migrations are applied in the order they are registered,
+// each one recorded by name in a dedicated bucket once it succeeds. If a
+// migration's Up fails, its Down (when provided) is used to undo whatever it
+// managed to write before the failure is returned, so the store is left as
+// if the failing migration had never run.
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/influxdata/influxdb/servicesv2/kv"
+)
+
+// migrationsBucket records the names of migrations that have already been
+// applied to the store, in the order they ran.
+var migrationsBucket = []byte("_migrations")
+
+// Migration is a single, named step in a store's schema history.
+type Migration struct {
+	Name string
+	// Up applies the migration. It must be idempotent: it runs against the
+	// raw SchemaStore rather than inside one transaction Migrator owns, so
+	// Up is free to open its own (possibly multiple) transactions, and a
+	// crash between Up succeeding and the migration being recorded as
+	// applied will cause Up to run again on the next Migrator.Up. Creating
+	// a bucket that may already exist, or backfilling an index from data
+	// that may already be backfilled, are both naturally idempotent; a
+	// migration that isn't needs to check its own prior effects before
+	// writing.
+	Up   func(context.Context, kv.SchemaStore) error
+	Down func(context.Context, kv.SchemaStore) error
+}
+
+// Migrator applies an ordered list of Migrations to a kv.SchemaStore,
+// keeping track of which ones have already run in migrationsBucket.
+type Migrator struct {
+	migrations []Migration
+}
+
+// New constructs a Migrator that will apply migrations in the given order.
+func New(migrations ...Migration) *Migrator {
+	return &Migrator{migrations: migrations}
+}
+
+// Up runs every migration that has not already been recorded as applied, in
+// order. Each migration is recorded as applied in its own transaction
+// immediately after it succeeds, so a later failure can't un-record an
+// earlier, already-committed migration. If a migration's Up fails, its Down
+// (if any) is invoked to undo it before the error is returned.
+//
+// A migration is not recorded as applied atomically with running it (see
+// Migration.Up's doc comment on why), so a crash between the two re-runs
+// that migration's Up on the next call to Up; migration authors must write
+// Up so that's safe.
+func (m *Migrator) Up(ctx context.Context, store kv.SchemaStore) error {
+	if err := store.CreateBucket(ctx, migrationsBucket); err != nil {
+		return fmt.Errorf("opening migrations bucket: %w", err)
+	}
+
+	applied, err := appliedNames(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	for i, mig := range m.migrations {
+		if applied[mig.Name] {
+			continue
+		}
+
+		if err := mig.Up(ctx, store); err != nil {
+			if mig.Down != nil {
+				if derr := mig.Down(ctx, store); derr != nil {
+					return fmt.Errorf("migration %d (%q) failed (%v) and rollback also failed: %w", i, mig.Name, err, derr)
+				}
+			}
+			return fmt.Errorf("running migration %d (%q): %w", i, mig.Name, err)
+		}
+
+		if err := recordApplied(ctx, store, mig.Name); err != nil {
+			return fmt.Errorf("recording migration %d (%q) as applied: %w", i, mig.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// appliedNames returns the set of migration names already recorded as
+// applied in migrationsBucket.
+func appliedNames(ctx context.Context, store kv.SchemaStore) (map[string]bool, error) {
+	applied := map[string]bool{}
+
+	err := store.View(ctx, func(tx kv.Tx) error {
+		bkt, err := tx.Bucket(migrationsBucket)
+		if err != nil {
+			return err
+		}
+
+		cursor, err := bkt.Cursor()
+		if err != nil {
+			return err
+		}
+
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			applied[string(k)] = true
+		}
+
+		return cursor.Err()
+	})
+
+	return applied, err
+}
+
+// recordApplied marks a migration as having run, by name, in migrationsBucket.
+func recordApplied(ctx context.Context, store kv.SchemaStore, name string) error {
+	return store.Update(ctx, func(tx kv.Tx) error {
+		bkt, err := tx.Bucket(migrationsBucket)
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(name), []byte{1})
+	})
+}
@@ -0,0 +1,272 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/influxdata/influxdb/servicesv2/kv"
+)
+
+// memStore is a minimal in-memory kv.SchemaStore, just enough to exercise
+// the Migrator without pulling in a real backend.
+type memStore struct {
+	buckets map[string]map[string][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{buckets: map[string]map[string][]byte{}}
+}
+
+func (s *memStore) CreateBucket(ctx context.Context, b []byte) error {
+	if _, ok := s.buckets[string(b)]; !ok {
+		s.buckets[string(b)] = map[string][]byte{}
+	}
+	return nil
+}
+
+func (s *memStore) DeleteBucket(ctx context.Context, b []byte) error {
+	delete(s.buckets, string(b))
+	return nil
+}
+
+func (s *memStore) View(ctx context.Context, fn func(kv.Tx) error) error {
+	return fn(&memTx{store: s})
+}
+
+func (s *memStore) Update(ctx context.Context, fn func(kv.Tx) error) error {
+	// Snapshot so a failing migration can be rolled back without leaving
+	// partial writes behind, mirroring a real backend's transaction.
+	snapshot := map[string]map[string][]byte{}
+	for b, kvs := range s.buckets {
+		cp := map[string][]byte{}
+		for k, v := range kvs {
+			cp[k] = v
+		}
+		snapshot[b] = cp
+	}
+
+	if err := fn(&memTx{store: s}); err != nil {
+		s.buckets = snapshot
+		return err
+	}
+
+	return nil
+}
+
+type memTx struct {
+	store *memStore
+}
+
+func (tx *memTx) Context() context.Context    { return context.Background() }
+func (tx *memTx) WithContext(context.Context) {}
+
+func (tx *memTx) Bucket(b []byte) (kv.Bucket, error) {
+	bkt, ok := tx.store.buckets[string(b)]
+	if !ok {
+		return nil, kv.ErrBucketNotFound
+	}
+	return &memBucket{kvs: bkt}, nil
+}
+
+type memBucket struct {
+	kvs map[string][]byte
+}
+
+func (b *memBucket) Get(key []byte) ([]byte, error) {
+	v, ok := b.kvs[string(key)]
+	if !ok {
+		return nil, kv.ErrKeyNotFound
+	}
+	return v, nil
+}
+
+func (b *memBucket) Put(key, value []byte) error {
+	b.kvs[string(key)] = value
+	return nil
+}
+
+func (b *memBucket) Delete(key []byte) error {
+	delete(b.kvs, string(key))
+	return nil
+}
+
+func (b *memBucket) Cursor(opts ...kv.CursorHint) (kv.Cursor, error) {
+	keys := make([]string, 0, len(b.kvs))
+	for k := range b.kvs {
+		keys = append(keys, k)
+	}
+	return &memCursor{bucket: b, keys: keys, pos: -1}, nil
+}
+
+type memCursor struct {
+	bucket *memBucket
+	keys   []string
+	pos    int
+}
+
+func (c *memCursor) First() ([]byte, []byte) {
+	c.pos = 0
+	return c.at(c.pos)
+}
+
+func (c *memCursor) Last() ([]byte, []byte) {
+	c.pos = len(c.keys) - 1
+	return c.at(c.pos)
+}
+
+func (c *memCursor) Next() ([]byte, []byte) {
+	c.pos++
+	return c.at(c.pos)
+}
+
+func (c *memCursor) Prev() ([]byte, []byte) {
+	c.pos--
+	return c.at(c.pos)
+}
+
+func (c *memCursor) Seek(key []byte) ([]byte, []byte) {
+	for i, k := range c.keys {
+		if k >= string(key) {
+			c.pos = i
+			return c.at(c.pos)
+		}
+	}
+	c.pos = len(c.keys)
+	return nil, nil
+}
+
+func (c *memCursor) at(i int) ([]byte, []byte) {
+	if i < 0 || i >= len(c.keys) {
+		return nil, nil
+	}
+	return []byte(c.keys[i]), c.bucket.kvs[c.keys[i]]
+}
+
+func (c *memCursor) Err() error { return nil }
+
+func TestMigrator(t *testing.T) {
+	t.Run("fresh open runs all migrations", func(t *testing.T) {
+		store := newMemStore()
+		var ran []string
+		m := New(
+			Migration{Name: "one", Up: func(context.Context, kv.SchemaStore) error {
+				ran = append(ran, "one")
+				return nil
+			}},
+			Migration{Name: "two", Up: func(context.Context, kv.SchemaStore) error {
+				ran = append(ran, "two")
+				return nil
+			}},
+		)
+
+		if err := m.Up(context.Background(), store); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ran) != 2 || ran[0] != "one" || ran[1] != "two" {
+			t.Fatalf("expected both migrations to run in order, got: %v", ran)
+		}
+	})
+
+	t.Run("reopening runs none", func(t *testing.T) {
+		store := newMemStore()
+		runs := 0
+		m := New(Migration{Name: "one", Up: func(context.Context, kv.SchemaStore) error {
+			runs++
+			return nil
+		}})
+
+		if err := m.Up(context.Background(), store); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Up(context.Background(), store); err != nil {
+			t.Fatal(err)
+		}
+
+		if runs != 1 {
+			t.Fatalf("expected migration to run exactly once, ran: %d", runs)
+		}
+	})
+
+	t.Run("adding a new migration re-opens cleanly", func(t *testing.T) {
+		store := newMemStore()
+		var ran []string
+		m := New(Migration{Name: "one", Up: func(context.Context, kv.SchemaStore) error {
+			ran = append(ran, "one")
+			return nil
+		}})
+		if err := m.Up(context.Background(), store); err != nil {
+			t.Fatal(err)
+		}
+
+		m2 := New(
+			Migration{Name: "one", Up: func(context.Context, kv.SchemaStore) error {
+				ran = append(ran, "one")
+				return nil
+			}},
+			Migration{Name: "two", Up: func(context.Context, kv.SchemaStore) error {
+				ran = append(ran, "two")
+				return nil
+			}},
+		)
+		if err := m2.Up(context.Background(), store); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ran) != 2 || ran[1] != "two" {
+			t.Fatalf("expected only the new migration to run on reopen, got: %v", ran)
+		}
+	})
+
+	t.Run("failing Up leaves the store untouched", func(t *testing.T) {
+		store := newMemStore()
+		boom := errors.New("boom")
+		var rolledBack bool
+		m := New(
+			Migration{Name: "create widgets", Up: func(ctx context.Context, s kv.SchemaStore) error {
+				return s.CreateBucket(ctx, []byte("widgets"))
+			}},
+			Migration{
+				Name: "break",
+				Up: func(ctx context.Context, s kv.SchemaStore) error {
+					if err := s.CreateBucket(ctx, []byte("half-written")); err != nil {
+						return err
+					}
+					return boom
+				},
+				Down: func(ctx context.Context, s kv.SchemaStore) error {
+					rolledBack = true
+					return s.DeleteBucket(ctx, []byte("half-written"))
+				},
+			},
+		)
+
+		if err := m.Up(context.Background(), store); err == nil {
+			t.Fatal("expected failing migration to return an error")
+		}
+		if !rolledBack {
+			t.Fatal("expected the failing migration's Down to run")
+		}
+		if _, ok := store.buckets["half-written"]; ok {
+			t.Fatal("expected the failing migration's bucket to be rolled back")
+		}
+		if _, ok := store.buckets["widgets"]; !ok {
+			t.Fatal("expected the earlier, already-applied migration to remain committed")
+		}
+
+		err := store.View(context.Background(), func(tx kv.Tx) error {
+			bkt, err := tx.Bucket([]byte("_migrations"))
+			if err != nil {
+				return err
+			}
+			if _, err := bkt.Get([]byte("break")); err != kv.ErrKeyNotFound {
+				t.Fatal("migration \"break\" should not be recorded as applied after failing")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package kv
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrKeyNotFound is returned when the key requested is not found.
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrBucketNotFound is returned when the bucket requested is not found.
+var ErrBucketNotFound = errors.New("bucket not found")
+
+// Store is an interface for a generic key value store. It is modeled after
+// the bolt transaction model, where a consumer can pass a function into
+// View or Update, and have it invoked within the scope of a read-only or
+// read-write transaction respectively.
+type Store interface {
+	// View opens a transaction that will not write to any data. It is
+	// useful for a consistent view on existing data.
+	View(context.Context, func(Tx) error) error
+	// Update opens a transaction that will mutate data.
+	Update(context.Context, func(Tx) error) error
+}
+
+// SchemaStore is a Store that can additionally have its buckets managed,
+// which migrations need in order to create or drop buckets outside of a
+// running transaction.
+type SchemaStore interface {
+	Store
+
+	// CreateBucket creates a bucket if it does not already exist.
+	CreateBucket(ctx context.Context, bucket []byte) error
+	// DeleteBucket deletes a bucket, if it exists.
+	DeleteBucket(ctx context.Context, bucket []byte) error
+}
+
+// Tx is a transaction in the store.
+type Tx interface {
+	// Bucket returns the bucket for the given name.
+	Bucket(b []byte) (Bucket, error)
+	// Context returns the context associated with this transaction.
+	Context() context.Context
+	// WithContext associates a context with this transaction.
+	WithContext(ctx context.Context)
+}
+
+// Bucket is a key/value pair within the store. Within a bucket all keys are
+// unique, and byte-sorted.
+type Bucket interface {
+	// Get returns a key within this bucket. Errors if key does not exist.
+	Get(key []byte) ([]byte, error)
+	// Cursor returns a cursor at the beginning of this bucket.
+	Cursor(opts ...CursorHint) (Cursor, error)
+	// Put should error if the transaction it was called in is not writable.
+	Put(key, value []byte) error
+	// Delete should error if the transaction it was called in is not writable.
+	Delete(key []byte) error
+}
+
+// CursorHint is a hint to provide the store to improve cursor performance.
+// Backends that can't take advantage of a given hint can ignore it.
+type CursorHint struct {
+	// Prefix hints that all keys iterated over have this prefix.
+	Prefix []byte
+}
+
+// WithCursorHintPrefix configures a cursor to only range over a prefix.
+func WithCursorHintPrefix(prefix []byte) CursorHint {
+	return CursorHint{Prefix: prefix}
+}
+
+// Cursor is an iterator over a set of key/value pairs in a bucket.
+type Cursor interface {
+	// Seek moves the cursor forward until a key is found >= to key.
+	Seek(key []byte) (k []byte, v []byte)
+	// First moves the cursor to the first key in the bucket.
+	First() (k []byte, v []byte)
+	// Last moves the cursor to the last key in the bucket.
+	Last() (k []byte, v []byte)
+	// Next moves the cursor to the next key in the bucket.
+	Next() (k []byte, v []byte)
+	// Prev moves the cursor to the previous key in the bucket.
+	Prev() (k []byte, v []byte)
+	// Err returns non-nil if an error occurred while iterating.
+	Err() error
+}
@@ -0,0 +1,16 @@
+package tenant
+
+// Buckets and indexes used by the rest of the tenant domain (organizations,
+// buckets and the user/resource mappings (URMs) between them). Their
+// encoding is owned elsewhere in the tenant package; they are declared here
+// so the migration list in migrations.go has a single place to look when
+// deciding what to create.
+var (
+	urmBucket          = []byte("userresourcemappingsv1")
+	urmByUserIndex     = []byte("urmbyuserindexv1")
+	urmByOrgIndex      = []byte("urmbyorgindexv1")
+	organizationBucket = []byte("organizationsv1")
+	organizationIndex  = []byte("organizationindexv1")
+	bucketBucket       = []byte("bucketsv1")
+	bucketIndex        = []byte("bucketindexv1")
+)
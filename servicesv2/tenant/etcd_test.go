@@ -0,0 +1,51 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb/servicesv2/etcdkv"
+	"github.com/influxdata/influxdb/servicesv2/kv"
+	"go.uber.org/zap/zaptest"
+)
+
+// testEtcdEndpointsEnv names the environment variable the etcd conformance
+// backend reads its cluster address from. It's intentionally opt-in: most
+// runs of this package don't have an etcd cluster handy, so NewTestEtcdStore
+// skips rather than failing when it isn't set.
+const testEtcdEndpointsEnv = "INFLUXDB_TEST_ETCD_ENDPOINTS"
+
+// NewTestEtcdStore opens a fresh, migrated kv.SchemaStore backed by the
+// etcd cluster named in INFLUXDB_TEST_ETCD_ENDPOINTS, for use with the
+// conformance suite in conformance_test.go. It does not namespace keys per
+// test run, so point it at a cluster (or etcd instance) that is safe to
+// wipe and don't run it concurrently against the same cluster.
+func NewTestEtcdStore(t *testing.T) (kv.SchemaStore, func(), error) {
+	endpoints := os.Getenv(testEtcdEndpointsEnv)
+	if endpoints == "" {
+		t.Skipf("skipping etcd conformance tests: set %s to a running etcd cluster to enable", testEtcdEndpointsEnv)
+	}
+
+	logger := zaptest.NewLogger(t)
+	s := etcdkv.NewKVStore(logger, strings.Split(endpoints, ","))
+	if err := s.Open(context.Background()); err != nil {
+		return nil, nil, fmt.Errorf("connecting to etcd: %w", err)
+	}
+
+	if err := s.DeleteAll(context.Background()); err != nil {
+		return nil, nil, fmt.Errorf("clearing etcd cluster before test: %w", err)
+	}
+
+	if err := NewStore(s).Open(context.Background()); err != nil {
+		t.Fatalf("Cannot run migrations: %v", err)
+	}
+
+	close := func() {
+		s.Close()
+	}
+
+	return s, close, nil
+}
@@ -0,0 +1,35 @@
+package tenant
+
+import "github.com/influxdata/influxdb"
+
+// EventKind describes what kind of mutation produced an Event.
+type EventKind string
+
+const (
+	EventCreated EventKind = "created"
+	EventUpdated EventKind = "updated"
+	EventDeleted EventKind = "deleted"
+)
+
+// ResourceKind identifies which tenant resource an Event is about.
+type ResourceKind string
+
+const (
+	ResourceUser         ResourceKind = "user"
+	ResourceOrganization ResourceKind = "organization"
+	ResourceBucket       ResourceKind = "bucket"
+	ResourceURM          ResourceKind = "urm"
+)
+
+// Event describes a single successful mutation of a tenant resource. Before
+// and After hold the resource's value immediately prior to and after the
+// change, typed as the resource's own struct (e.g. *influxdb.User); Before
+// is nil for EventCreated and After is nil for EventDeleted.
+type Event struct {
+	Kind     EventKind
+	Resource ResourceKind
+	ID       influxdb.ID
+	OrgID    influxdb.ID
+	Before   interface{}
+	After    interface{}
+}
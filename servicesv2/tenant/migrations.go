@@ -0,0 +1,207 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/servicesv2/kv"
+	"github.com/influxdata/influxdb/servicesv2/kv/migrate"
+)
+
+// tenantMigrations is the ordered list of migrations applied to a tenant
+// Store's underlying kv.SchemaStore. Append new migrations to the end of
+// this list; never reorder or remove an entry that has already shipped.
+var tenantMigrations = []migrate.Migration{
+	{
+		Name: "create tenant buckets",
+		Up:   createTenantBuckets,
+		Down: dropTenantBuckets,
+	},
+	{
+		Name: "backfill urmByUserIndex from urmBucket",
+		Up:   backfillURMByUserIndex,
+		Down: func(context.Context, kv.SchemaStore) error { return nil },
+	},
+	{
+		Name: "create and backfill userStatusIndex from userBucket",
+		Up:   backfillUserStatusIndex,
+		Down: func(ctx context.Context, store kv.SchemaStore) error {
+			return store.DeleteBucket(ctx, userStatusIndex)
+		},
+	},
+	{
+		Name: "create and backfill urmByOrgIndex from urmBucket",
+		Up:   backfillURMByOrgIndex,
+		Down: func(ctx context.Context, store kv.SchemaStore) error {
+			return store.DeleteBucket(ctx, urmByOrgIndex)
+		},
+	},
+}
+
+// tenantBuckets lists every bucket the tenant domain stores data in.
+var tenantBuckets = [][]byte{
+	userBucket,
+	userpasswordBucket,
+	userIndex,
+	urmBucket,
+	organizationBucket,
+	organizationIndex,
+	bucketBucket,
+	bucketIndex,
+	urmByUserIndex,
+}
+
+func createTenantBuckets(ctx context.Context, store kv.SchemaStore) error {
+	for _, b := range tenantBuckets {
+		if err := store.CreateBucket(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropTenantBuckets(ctx context.Context, store kv.SchemaStore) error {
+	for _, b := range tenantBuckets {
+		if err := store.DeleteBucket(ctx, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// urmRecord is the subset of a URM's JSON encoding this migration needs in
+// order to rebuild urmByUserIndex; the full URM shape is owned elsewhere in
+// the tenant package.
+type urmRecord struct {
+	UserID influxdb.ID
+	OrgID  influxdb.ID
+}
+
+// backfillURMByUserIndex rebuilds urmByUserIndex by scanning every existing
+// entry in urmBucket and indexing it under its UserID, so URM lookups by
+// user don't need a full bucket scan.
+func backfillURMByUserIndex(ctx context.Context, store kv.SchemaStore) error {
+	return store.Update(ctx, func(tx kv.Tx) error {
+		urms, err := tx.Bucket(urmBucket)
+		if err != nil {
+			return err
+		}
+
+		idx, err := tx.Bucket(urmByUserIndex)
+		if err != nil {
+			return err
+		}
+
+		cursor, err := urms.Cursor()
+		if err != nil {
+			return err
+		}
+
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var rec urmRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+
+			userID, err := rec.UserID.Encode()
+			if err != nil {
+				return err
+			}
+
+			if err := idx.Put(append(append([]byte{}, userID...), k...), k); err != nil {
+				return err
+			}
+		}
+
+		return cursor.Err()
+	})
+}
+
+// backfillURMByOrgIndex creates urmByOrgIndex (it didn't exist when "create
+// tenant buckets" ran for stores migrated before this change shipped) and
+// populates it by scanning every existing entry in urmBucket, so ListUsers
+// can filter by org without a full scan of urmBucket.
+func backfillURMByOrgIndex(ctx context.Context, store kv.SchemaStore) error {
+	if err := store.CreateBucket(ctx, urmByOrgIndex); err != nil {
+		return err
+	}
+
+	return store.Update(ctx, func(tx kv.Tx) error {
+		urms, err := tx.Bucket(urmBucket)
+		if err != nil {
+			return err
+		}
+
+		idx, err := tx.Bucket(urmByOrgIndex)
+		if err != nil {
+			return err
+		}
+
+		cursor, err := urms.Cursor()
+		if err != nil {
+			return err
+		}
+
+		for _, v := cursor.First(); v != nil; _, v = cursor.Next() {
+			var rec urmRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+
+			encodedOrgID, err := rec.OrgID.Encode()
+			if err != nil {
+				return err
+			}
+			encodedUserID, err := rec.UserID.Encode()
+			if err != nil {
+				return err
+			}
+
+			if err := idx.Put(orgIndexKey(encodedOrgID, encodedUserID), encodedUserID); err != nil {
+				return err
+			}
+		}
+
+		return cursor.Err()
+	})
+}
+
+// backfillUserStatusIndex creates userStatusIndex (it didn't exist when
+// "create tenant buckets" ran for stores migrated before this change
+// shipped) and populates it by scanning every existing entry in userBucket.
+func backfillUserStatusIndex(ctx context.Context, store kv.SchemaStore) error {
+	if err := store.CreateBucket(ctx, userStatusIndex); err != nil {
+		return err
+	}
+
+	return store.Update(ctx, func(tx kv.Tx) error {
+		users, err := tx.Bucket(userBucket)
+		if err != nil {
+			return err
+		}
+
+		idx, err := tx.Bucket(userStatusIndex)
+		if err != nil {
+			return err
+		}
+
+		cursor, err := users.Cursor()
+		if err != nil {
+			return err
+		}
+
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var u influxdb.User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+
+			if err := idx.Put(statusIndexKey(u.Status, k), k); err != nil {
+				return err
+			}
+		}
+
+		return cursor.Err()
+	})
+}
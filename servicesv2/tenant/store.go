@@ -0,0 +1,69 @@
+package tenant
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/influxdb/servicesv2/kv"
+	"github.com/influxdata/influxdb/servicesv2/kv/migrate"
+)
+
+// Store wraps a kv.SchemaStore and exposes the tenant domain (users,
+// organizations, buckets and the user/resource mappings between them) on
+// top of it.
+type Store struct {
+	kv kv.SchemaStore
+
+	subsMu sync.RWMutex
+	subs   []*subscriber
+}
+
+// NewStore creates a new tenant Store over the given kv.SchemaStore. It does
+// not run migrations; call Open for that.
+func NewStore(store kv.SchemaStore) *Store {
+	return &Store{kv: store}
+}
+
+// Open brings the underlying store's schema up to date by running any
+// pending migrations. It must be called once before the Store is used, and
+// is safe to call repeatedly: migrations that have already run are skipped.
+func (s *Store) Open(ctx context.Context) error {
+	return migrator().Up(ctx, s.kv)
+}
+
+// View opens up a read-only transaction against the store.
+func (s *Store) View(ctx context.Context, fn func(kv.Tx) error) error {
+	return s.kv.View(ctx, fn)
+}
+
+// Update opens up a read-write transaction against the store. Any Events
+// raised by mutations inside fn (see event.go) are only delivered to
+// subscribers once the underlying transaction commits; if fn or the
+// transaction itself fails, they are discarded along with the write.
+func (s *Store) Update(ctx context.Context, fn func(kv.Tx) error) error {
+	// buf is reassigned on every call to the closure below, not just once
+	// per Update: backends like etcdkv retry the closure on optimistic-
+	// concurrency conflicts, and reusing one buffer across attempts would
+	// flush events from abandoned attempts alongside the one that actually
+	// committed.
+	var buf *eventBuffer
+
+	err := s.kv.Update(ctx, func(tx kv.Tx) error {
+		buf = &eventBuffer{}
+		tx.WithContext(context.WithValue(tx.Context(), eventBufferKey{}, buf))
+		return fn(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publish(buf.events)
+	return nil
+}
+
+// migrator returns the Migrator responsible for bringing a tenant store's
+// schema up to date. New migrations should be appended to the end of this
+// list, never inserted or reordered.
+func migrator() *migrate.Migrator {
+	return migrate.New(tenantMigrations...)
+}
@@ -0,0 +1,201 @@
+package tenant
+
+import (
+	"context"
+	"sync"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/servicesv2/kv"
+)
+
+// SubscriptionFilter narrows which Events a subscriber receives. A nil
+// field matches every value for that dimension.
+type SubscriptionFilter struct {
+	Resource *ResourceKind
+	ID       *influxdb.ID
+	OrgID    *influxdb.ID
+}
+
+func (f SubscriptionFilter) matches(ev Event) bool {
+	if f.Resource != nil && *f.Resource != ev.Resource {
+		return false
+	}
+	if f.ID != nil && *f.ID != ev.ID {
+		return false
+	}
+	if f.OrgID != nil && *f.OrgID != ev.OrgID {
+		return false
+	}
+	return true
+}
+
+// OverflowPolicy controls what a subscription does when its subscriber
+// isn't draining events fast enough to keep up with the bounded channel
+// Subscribe hands back.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest buffered event to make room for the
+	// new one, so a slow subscriber loses history but stays connected.
+	DropOldest OverflowPolicy = iota
+	// Disconnect closes the subscriber's channel once its buffer is full,
+	// so a slow subscriber is told unambiguously that it missed events.
+	Disconnect
+)
+
+// defaultSubscriptionBuffer is how many Events a subscriber's channel holds
+// before its OverflowPolicy kicks in, if WithBufferSize isn't given.
+const defaultSubscriptionBuffer = 16
+
+// SubscribeOption configures a call to Store.Subscribe.
+type SubscribeOption func(*subscriber)
+
+// WithBufferSize sets how many Events a subscriber's channel can hold
+// before its OverflowPolicy applies.
+func WithBufferSize(n int) SubscribeOption {
+	return func(s *subscriber) { s.bufferSize = n }
+}
+
+// WithOverflowPolicy sets what happens when a subscriber's channel is full.
+func WithOverflowPolicy(p OverflowPolicy) SubscribeOption {
+	return func(s *subscriber) { s.policy = p }
+}
+
+// subscriber is one consumer registered via Store.Subscribe.
+type subscriber struct {
+	filter     SubscriptionFilter
+	bufferSize int
+	policy     OverflowPolicy
+
+	mu     sync.Mutex
+	ch     chan Event
+	closed bool
+}
+
+func (s *subscriber) send(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.ch <- ev:
+		return
+	default:
+	}
+
+	switch s.policy {
+	case DropOldest:
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- ev:
+		default:
+		}
+	case Disconnect:
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+func (s *subscriber) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// Subscribe registers a change-feed subscription for every Event matching
+// filter, produced by mutations committed through this Store after
+// Subscribe returns. The returned channel is closed, and the subscription
+// removed, once ctx is done.
+func (s *Store) Subscribe(ctx context.Context, filter SubscriptionFilter, opts ...SubscribeOption) (<-chan Event, error) {
+	sub := &subscriber{
+		filter:     filter,
+		bufferSize: defaultSubscriptionBuffer,
+		policy:     DropOldest,
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	sub.ch = make(chan Event, sub.bufferSize)
+
+	s.subsMu.Lock()
+	s.subs = append(s.subs, sub)
+	s.subsMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.removeSubscriber(sub)
+	}()
+
+	return sub.ch, nil
+}
+
+func (s *Store) removeSubscriber(sub *subscriber) {
+	s.subsMu.Lock()
+	for i, existing := range s.subs {
+		if existing == sub {
+			s.subs = append(s.subs[:i], s.subs[i+1:]...)
+			break
+		}
+	}
+	s.subsMu.Unlock()
+
+	sub.disconnect()
+}
+
+// publish delivers ev to every currently-registered subscriber whose filter
+// matches it.
+func (s *Store) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	s.subsMu.RLock()
+	subs := make([]*subscriber, len(s.subs))
+	copy(subs, s.subs)
+	s.subsMu.RUnlock()
+
+	for _, ev := range events {
+		for _, sub := range subs {
+			if sub.filter.matches(ev) {
+				sub.send(ev)
+			}
+		}
+	}
+}
+
+// eventBufferKey is the context key an in-flight transaction's event buffer
+// is stored under, attached via tx.WithContext by Store.Update.
+type eventBufferKey struct{}
+
+// eventBuffer accumulates Events raised during a single kv.Tx. Store.Update
+// flushes it to subscribers only after the underlying transaction commits
+// successfully, so a rolled-back transaction never produces an Event.
+type eventBuffer struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (b *eventBuffer) add(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, ev)
+}
+
+// recordEvent buffers ev against the transaction it was raised in, to be
+// flushed once that transaction commits. It is a no-op if tx wasn't opened
+// through Store.Update (for example, a bare kv.Tx passed in directly by a
+// test), since there is nowhere to flush to.
+func recordEvent(tx kv.Tx, ev Event) {
+	if buf, ok := tx.Context().Value(eventBufferKey{}).(*eventBuffer); ok {
+		buf.add(ev)
+	}
+}
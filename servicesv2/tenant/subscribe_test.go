@@ -0,0 +1,142 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/servicesv2/kv"
+)
+
+// TestUserSubscribe runs the subscription scenario against every backend
+// registered in storeFactories (see conformance_test.go), so a backend whose
+// Update retries duplicate or drop events fails the same way bolt's own
+// regressions would.
+func TestUserSubscribe(t *testing.T) {
+	for _, factory := range storeFactories {
+		factory := factory
+		t.Run(factory.name, func(t *testing.T) {
+			runSubscribeScenario(t, factory.new)
+		})
+	}
+}
+
+func runSubscribeScenario(t *testing.T, newStore func(*testing.T) (kv.SchemaStore, func(), error)) {
+	s, closeS, err := newStore(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeS()
+
+	ts := NewStore(s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := ts.Subscribe(ctx, SubscriptionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ts.Update(context.Background(), func(tx kv.Tx) error {
+		for i := 1; i <= 10; i++ {
+			err := ts.CreateUser(context.Background(), tx, &influxdb.User{
+				ID:     influxdb.ID(i),
+				Name:   fmt.Sprintf("user%d", i),
+				Status: "active",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ts.Update(context.Background(), func(tx kv.Tx) error {
+		user30 := "user30"
+		if _, err := ts.UpdateUser(context.Background(), tx, influxdb.ID(3), influxdb.UserUpdate{Name: &user30}); err != nil {
+			return err
+		}
+
+		inactive := influxdb.Status("inactive")
+		_, err := ts.UpdateUser(context.Background(), tx, influxdb.ID(3), influxdb.UserUpdate{Status: &inactive})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ts.Update(context.Background(), func(tx kv.Tx) error {
+		if err := ts.DeleteUser(context.Background(), tx, influxdb.ID(1)); err != nil {
+			return err
+		}
+		return ts.DeleteUser(context.Background(), tx, influxdb.ID(3))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Event
+	for i := 0; i < 14; i++ {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %d, only received %d", i, len(got))
+		}
+	}
+
+	if len(got) != 14 {
+		t.Fatalf("expected 14 events, got %d", len(got))
+	}
+
+	for i := 0; i < 10; i++ {
+		ev := got[i]
+		if ev.Kind != EventCreated || ev.Resource != ResourceUser || ev.ID != influxdb.ID(i+1) {
+			t.Fatalf("event %d: expected create of user %d, got %+v", i, i+1, ev)
+		}
+		after, ok := ev.After.(*influxdb.User)
+		if !ok || after.Name != fmt.Sprintf("user%d", i+1) {
+			t.Fatalf("event %d: unexpected After snapshot: %+v", i, ev.After)
+		}
+	}
+
+	rename := got[10]
+	if rename.Kind != EventUpdated || rename.ID != influxdb.ID(3) {
+		t.Fatalf("expected rename update event for user 3, got %+v", rename)
+	}
+	before, ok := rename.Before.(*influxdb.User)
+	if !ok || before.Name != "user3" {
+		t.Fatalf("expected rename Before snapshot with name \"user3\", got %+v", rename.Before)
+	}
+	after, ok := rename.After.(*influxdb.User)
+	if !ok || after.Name != "user30" {
+		t.Fatalf("expected rename After snapshot with name \"user30\", got %+v", rename.After)
+	}
+
+	statusChange := got[11]
+	if statusChange.Kind != EventUpdated || statusChange.ID != influxdb.ID(3) {
+		t.Fatalf("expected status update event for user 3, got %+v", statusChange)
+	}
+	before, ok = statusChange.Before.(*influxdb.User)
+	if !ok || before.Status != "active" {
+		t.Fatalf("expected status change Before snapshot with status \"active\", got %+v", statusChange.Before)
+	}
+	after, ok = statusChange.After.(*influxdb.User)
+	if !ok || after.Status != "inactive" {
+		t.Fatalf("expected status change After snapshot with status \"inactive\", got %+v", statusChange.After)
+	}
+
+	deletedIDs := []influxdb.ID{got[12].ID, got[13].ID}
+	if got[12].Kind != EventDeleted || got[13].Kind != EventDeleted {
+		t.Fatalf("expected final two events to be deletes, got %+v and %+v", got[12], got[13])
+	}
+	if !(deletedIDs[0] == influxdb.ID(1) && deletedIDs[1] == influxdb.ID(3)) {
+		t.Fatalf("expected deletes for users 1 then 3, got %v", deletedIDs)
+	}
+}
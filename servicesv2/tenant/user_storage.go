@@ -0,0 +1,444 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/influxdata/influxdb"
+	"github.com/influxdata/influxdb/servicesv2/kv"
+)
+
+// Buckets used to store users and the indexes used to look them up by name
+// and by status.
+var (
+	userBucket         = []byte("usersv1")
+	userpasswordBucket = []byte("userspasswordv1")
+	userIndex          = []byte("userindexv1")
+	userStatusIndex    = []byte("userstatusindexv1")
+)
+
+// ErrUserNotFound is returned when the user requested does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// UserAlreadyExistsError is used when attempting to create a user with a
+// name that already exists.
+func UserAlreadyExistsError(name string) error {
+	return fmt.Errorf("user with name %s already exists", name)
+}
+
+// UserFilter narrows a ListUsers call. A nil field matches every user. Name,
+// NamePrefix, Status and OrgID are each backed by a secondary index, so none
+// of them require a full scan of userBucket.
+type UserFilter struct {
+	Name       *string
+	NamePrefix *string
+	Status     *influxdb.Status
+	OrgID      *influxdb.ID
+}
+
+// statusIndexKey builds the userStatusIndex key for a user with the given
+// status and encoded ID: statuses sort together, and the ID suffix keeps
+// entries for the same status unique.
+func statusIndexKey(status influxdb.Status, encodedID []byte) []byte {
+	key := make([]byte, 0, len(status)+1+len(encodedID))
+	key = append(key, []byte(status)...)
+	key = append(key, '/')
+	key = append(key, encodedID...)
+	return key
+}
+
+// orgIndexKey builds the urmByOrgIndex key for a URM under the given
+// encoded org and user IDs: entries for the same org sort together, and the
+// user ID suffix keeps them unique.
+func orgIndexKey(encodedOrgID, encodedUserID []byte) []byte {
+	key := make([]byte, 0, len(encodedOrgID)+1+len(encodedUserID))
+	key = append(key, encodedOrgID...)
+	key = append(key, '/')
+	key = append(key, encodedUserID...)
+	return key
+}
+
+func putUserIndexes(tx kv.Tx, u *influxdb.User) error {
+	encodedID, err := u.ID.Encode()
+	if err != nil {
+		return err
+	}
+
+	nameIdx, err := tx.Bucket(userIndex)
+	if err != nil {
+		return err
+	}
+	if err := nameIdx.Put([]byte(u.Name), encodedID); err != nil {
+		return err
+	}
+
+	statusIdx, err := tx.Bucket(userStatusIndex)
+	if err != nil {
+		return err
+	}
+	return statusIdx.Put(statusIndexKey(u.Status, encodedID), encodedID)
+}
+
+func deleteUserIndexes(tx kv.Tx, u *influxdb.User) error {
+	encodedID, err := u.ID.Encode()
+	if err != nil {
+		return err
+	}
+
+	nameIdx, err := tx.Bucket(userIndex)
+	if err != nil {
+		return err
+	}
+	if err := nameIdx.Delete([]byte(u.Name)); err != nil {
+		return err
+	}
+
+	statusIdx, err := tx.Bucket(userStatusIndex)
+	if err != nil {
+		return err
+	}
+	return statusIdx.Delete(statusIndexKey(u.Status, encodedID))
+}
+
+// CreateUser creates a new user, failing if a user with the same name
+// already exists.
+func (s *Store) CreateUser(ctx context.Context, tx kv.Tx, u *influxdb.User) error {
+	if _, err := s.GetUserByName(ctx, tx, u.Name); err == nil {
+		return UserAlreadyExistsError(u.Name)
+	}
+
+	if err := putUserIndexes(tx, u); err != nil {
+		return err
+	}
+
+	if err := s.putUser(ctx, tx, u); err != nil {
+		return err
+	}
+
+	recordEvent(tx, Event{Kind: EventCreated, Resource: ResourceUser, ID: u.ID, After: u})
+	return nil
+}
+
+// putUser writes the user to the user bucket, overwriting any existing
+// entry for the same ID.
+func (s *Store) putUser(ctx context.Context, tx kv.Tx, u *influxdb.User) error {
+	v, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	encodedID, err := u.ID.Encode()
+	if err != nil {
+		return err
+	}
+
+	bkt, err := tx.Bucket(userBucket)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Put(encodedID, v)
+}
+
+// GetUser retrieves a user by ID.
+func (s *Store) GetUser(ctx context.Context, tx kv.Tx, id influxdb.ID) (*influxdb.User, error) {
+	encodedID, err := id.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	bkt, err := tx.Bucket(userBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := bkt.Get(encodedID)
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	u := &influxdb.User{}
+	if err := json.Unmarshal(v, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// GetUserByName retrieves a user by its name, using the userIndex bucket.
+func (s *Store) GetUserByName(ctx context.Context, tx kv.Tx, name string) (*influxdb.User, error) {
+	idx, err := tx.Bucket(userIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedID, err := idx.Get([]byte(name))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	var id influxdb.ID
+	if err := id.Decode(encodedID); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, tx, id)
+}
+
+// ListUsers returns users matching filter, in ID order, honoring any
+// FindOptions passed (Limit, Offset, and After). Every field on filter
+// narrows the scan via a secondary index (see candidateIDs) rather than
+// walking every user in userBucket.
+func (s *Store) ListUsers(ctx context.Context, tx kv.Tx, filter UserFilter, opt ...influxdb.FindOptions) ([]*influxdb.User, error) {
+	var options influxdb.FindOptions
+	if len(opt) > 0 {
+		options = opt[0]
+	}
+
+	candidates, scoped, err := candidateIDs(tx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	bkt, err := tx.Bucket(userBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := bkt.Cursor()
+	if err != nil {
+		return nil, err
+	}
+
+	k, v := cursor.First()
+	if options.After != nil {
+		afterID, err := options.After.Encode()
+		if err != nil {
+			return nil, err
+		}
+		for k != nil && string(k) <= string(afterID) {
+			k, v = cursor.Next()
+		}
+	}
+
+	var users []*influxdb.User
+	skipped := 0
+	for ; k != nil; k, v = cursor.Next() {
+		if scoped && !candidates[string(k)] {
+			continue
+		}
+
+		if skipped < options.Offset {
+			skipped++
+			continue
+		}
+
+		if options.Limit > 0 && len(users) >= options.Limit {
+			break
+		}
+
+		u := &influxdb.User{}
+		if err := json.Unmarshal(v, u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, cursor.Err()
+}
+
+// candidateIDs resolves filter down to the set of encoded user IDs it
+// matches, using secondary indexes where one exists. scoped is false when
+// filter doesn't restrict the result at all, in which case candidates is
+// nil and every user in userBucket should be considered.
+func candidateIDs(tx kv.Tx, filter UserFilter) (candidates map[string]bool, scoped bool, err error) {
+	candidates = map[string]bool{}
+
+	if filter.Name != nil {
+		idx, err := tx.Bucket(userIndex)
+		if err != nil {
+			return nil, false, err
+		}
+		matched := map[string]bool{}
+		encodedID, err := idx.Get([]byte(*filter.Name))
+		if err != nil && err != kv.ErrKeyNotFound {
+			return nil, false, err
+		}
+		if err == nil {
+			matched[string(encodedID)] = true
+		}
+		candidates, scoped = intersect(candidates, scoped, matched), true
+	}
+
+	if filter.NamePrefix != nil {
+		idx, err := tx.Bucket(userIndex)
+		if err != nil {
+			return nil, false, err
+		}
+		cursor, err := idx.Cursor(kv.WithCursorHintPrefix([]byte(*filter.NamePrefix)))
+		if err != nil {
+			return nil, false, err
+		}
+		matched := map[string]bool{}
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			matched[string(v)] = true
+		}
+		if err := cursor.Err(); err != nil {
+			return nil, false, err
+		}
+		candidates, scoped = intersect(candidates, scoped, matched), true
+	}
+
+	if filter.Status != nil {
+		idx, err := tx.Bucket(userStatusIndex)
+		if err != nil {
+			return nil, false, err
+		}
+		cursor, err := idx.Cursor(kv.WithCursorHintPrefix(statusIndexKey(*filter.Status, nil)))
+		if err != nil {
+			return nil, false, err
+		}
+		matched := map[string]bool{}
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			matched[string(v)] = true
+		}
+		if err := cursor.Err(); err != nil {
+			return nil, false, err
+		}
+		candidates, scoped = intersect(candidates, scoped, matched), true
+	}
+
+	if filter.OrgID != nil {
+		matched, err := usersInOrg(tx, *filter.OrgID)
+		if err != nil {
+			return nil, false, err
+		}
+		candidates, scoped = intersect(candidates, scoped, matched), true
+	}
+
+	if !scoped {
+		return nil, false, nil
+	}
+	return candidates, true, nil
+}
+
+// intersect narrows candidates down to the IDs also present in with. The
+// first filter applied starts from an empty, unscoped candidates map, so it
+// simply adopts with instead of intersecting against nothing.
+func intersect(candidates map[string]bool, scoped bool, with map[string]bool) map[string]bool {
+	if !scoped {
+		return with
+	}
+	out := map[string]bool{}
+	for id := range candidates {
+		if with[id] {
+			out[id] = true
+		}
+	}
+	return out
+}
+
+// usersInOrg resolves orgID to the set of user IDs mapped to it, using
+// urmByOrgIndex (urmByUserIndex can't serve this: it's keyed the other way,
+// by user). It's only reached when a caller filters ListUsers by OrgID.
+func usersInOrg(tx kv.Tx, orgID influxdb.ID) (map[string]bool, error) {
+	encodedOrgID, err := orgID.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := tx.Bucket(urmByOrgIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := idx.Cursor(kv.WithCursorHintPrefix(orgIndexKey(encodedOrgID, nil)))
+	if err != nil {
+		return nil, err
+	}
+
+	matched := map[string]bool{}
+	for _, v := cursor.First(); v != nil; _, v = cursor.Next() {
+		matched[string(v)] = true
+	}
+
+	return matched, cursor.Err()
+}
+
+// UpdateUser applies the given update to the user with id, returning the
+// updated user.
+func (s *Store) UpdateUser(ctx context.Context, tx kv.Tx, id influxdb.ID, upd influxdb.UserUpdate) (*influxdb.User, error) {
+	u, err := s.GetUser(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *u
+
+	if upd.Name != nil && *upd.Name != u.Name {
+		if _, err := s.GetUserByName(ctx, tx, *upd.Name); err == nil {
+			return nil, UserAlreadyExistsError(*upd.Name)
+		}
+	}
+
+	if (upd.Name != nil && *upd.Name != u.Name) || (upd.Status != nil && *upd.Status != u.Status) {
+		if err := deleteUserIndexes(tx, u); err != nil {
+			return nil, err
+		}
+
+		if upd.Name != nil {
+			u.Name = *upd.Name
+		}
+		if upd.Status != nil {
+			u.Status = *upd.Status
+		}
+
+		if err := putUserIndexes(tx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.putUser(ctx, tx, u); err != nil {
+		return nil, err
+	}
+
+	recordEvent(tx, Event{Kind: EventUpdated, Resource: ResourceUser, ID: u.ID, Before: &before, After: u})
+	return u, nil
+}
+
+// DeleteUser removes a user from the store.
+func (s *Store) DeleteUser(ctx context.Context, tx kv.Tx, id influxdb.ID) error {
+	u, err := s.GetUser(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteUserIndexes(tx, u); err != nil {
+		return err
+	}
+
+	encodedID, err := id.Encode()
+	if err != nil {
+		return err
+	}
+
+	bkt, err := tx.Bucket(userBucket)
+	if err != nil {
+		return err
+	}
+
+	if err := bkt.Delete(encodedID); err != nil {
+		return err
+	}
+
+	recordEvent(tx, Event{Kind: EventDeleted, Resource: ResourceUser, ID: u.ID, Before: u})
+	return nil
+}